@@ -0,0 +1,128 @@
+package captcha
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/xv13r/captcha/internal/audio"
+)
+
+// beepSound holds the raw 8kHz unsigned 8-bit PCM samples (no WAV header)
+// played at the end of a captcha's audio. sounds.go initializes it from
+// beep.wav via an init func when cmd/generate was given one; SetBeep always
+// overrides whatever that loaded. Nil means no trailing beep at all.
+var beepSound []byte
+
+// digitGap holds configurable inter-digit silence, synthesized the same way
+// as beepSound via SetDigitGap. Nil by default, i.e. no gap beyond whatever
+// is already baked into each digit's audio.
+var digitGap []byte
+
+// BeepSpec describes how to synthesize the captcha's trailing beep at
+// runtime, without shipping a beep.wav. Exactly one of Silence or Tone must
+// be set.
+type BeepSpec struct {
+	Silence *Silence
+	Tone    *Tone
+}
+
+// Silence synthesizes Duration of flat silence.
+type Silence struct {
+	Duration time.Duration
+}
+
+// Tone synthesizes a sine wave at Freq for Duration. FadeIn and FadeOut are
+// linear amplitude ramps at the start and end, long enough to avoid audible
+// clicks; Amplitude is in [0, 1].
+type Tone struct {
+	Freq            float64
+	Duration        time.Duration
+	FadeIn, FadeOut time.Duration
+	Amplitude       float64
+}
+
+// SetBeep synthesizes spec and installs it as beepSound, replacing whatever
+// sounds.go may have loaded from beep.wav. This lets callers tune or drop
+// the trailing beep without shipping or regenerating any asset.
+func SetBeep(spec BeepSpec) error {
+	pcm, err := spec.synthesize()
+	if err != nil {
+		return fmt.Errorf("captcha: SetBeep: %w", err)
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	beepSound = pcm
+	// Whatever sounds.go baked in no longer applies; don't let VerifyAssets
+	// flag the synthesized beep as corrupted.
+	beepSoundHash = [32]byte{}
+	return nil
+}
+
+// SetDigitGap synthesizes dur of silence to play between consecutive
+// digits, replacing any previous gap. A zero or negative duration removes
+// the gap.
+func SetDigitGap(dur time.Duration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	digitGap = synthesizeSilence(dur)
+}
+
+func (s BeepSpec) synthesize() ([]byte, error) {
+	switch {
+	case s.Silence != nil && s.Tone != nil:
+		return nil, fmt.Errorf("BeepSpec: only one of Silence or Tone may be set")
+	case s.Silence != nil:
+		return synthesizeSilence(s.Silence.Duration), nil
+	case s.Tone != nil:
+		return synthesizeTone(*s.Tone), nil
+	default:
+		return nil, fmt.Errorf("BeepSpec: one of Silence or Tone must be set")
+	}
+}
+
+func synthesizeSilence(dur time.Duration) []byte {
+	out := make([]byte, samplesFor(dur))
+	for i := range out {
+		out[i] = 128 // mid-point of unsigned 8-bit PCM == zero amplitude
+	}
+	return out
+}
+
+func synthesizeTone(t Tone) []byte {
+	n := samplesFor(t.Duration)
+	fadeInN := samplesFor(t.FadeIn)
+	fadeOutN := samplesFor(t.FadeOut)
+
+	out := make([]byte, n)
+	for i := 0; i < n; i++ {
+		tSec := float64(i) / float64(audio.TargetSampleRate)
+		amp := t.Amplitude
+		if fadeInN > 0 && i < fadeInN {
+			amp *= float64(i) / float64(fadeInN)
+		}
+		if fadeOutN > 0 && i >= n-fadeOutN {
+			amp *= float64(n-1-i) / float64(fadeOutN)
+		}
+		sample := 128 + math.Round(127*amp*math.Sin(2*math.Pi*t.Freq*tSec))
+		out[i] = uint8(clampSample(sample))
+	}
+	return out
+}
+
+func samplesFor(dur time.Duration) int {
+	if dur <= 0 {
+		return 0
+	}
+	return int(math.Round(dur.Seconds() * float64(audio.TargetSampleRate)))
+}
+
+func clampSample(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
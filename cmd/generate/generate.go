@@ -1,13 +1,18 @@
-// Command generate builds sounds.go from language folders with 0.wav..9.wav each.
+// Command generate builds sounds.go from language folders with 0..9 digit
+// source files each.
 //
 // Example:
 //   go run ./cmd/generate/generate.go -in . -out sounds.go -pkg captcha -langs en,es,ja,pt,ru,zh
 //
 // Input structure:
 //   ./en/0.wav .. 9.wav
-//   ./es/0.wav .. 9.wav
+//   ./es/0.flac .. 9.flac
 //   ...
 //
+// Each digit slot may be provided as .wav, .flac, .ogg, or .opus; the
+// extension is dispatched on a per-file basis, so a language folder may even
+// mix formats across digits.
+//
 // Output: a Go file that defines:
 //   - var waveHeader = []byte{ ... }   // mono 8kHz 8-bit PCM RIFF header (sizes 0)
 //   - var digitSounds = map[string][][]byte{ "en": { /* 0..9 */ }, "es": {...}, ... }
@@ -16,42 +21,30 @@ package main
 
 import (
 	"bytes"
-	"encoding/binary"
+	"crypto/sha256"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"go/format"
-	"io"
-	"math"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
-)
 
-var (
-	inDir   = flag.String("in", ".", "Input directory containing language subfolders (en, es, ...)")
-	outFile = flag.String("out", "sounds.go", "Output Go source file to generate")
-	pkgName = flag.String("pkg", "captcha", "Package name for the generated Go file")
-	langs   = flag.String("langs", "", "Comma-separated languages to include (default: auto-detect subfolders)")
-	beep    = flag.String("beep", "", "Optional path to beep.wav (default: <in>/beep.wav). If missing, beepSound is omitted.")
+	"github.com/xv13r/captcha/internal/audio"
 )
 
-// Target audio format for captcha: mono 8kHz unsigned 8-bit PCM (no header in digit bytes).
-const (
-	targetSR       = 8000
-	targetBits     = 8
-	targetChannels = 1
+var (
+	inDir        = flag.String("in", ".", "Input directory containing language subfolders (en, es, ...)")
+	outFile      = flag.String("out", "sounds.go", "Output Go source file to generate")
+	pkgName      = flag.String("pkg", "captcha", "Package name for the generated Go file")
+	langs        = flag.String("langs", "", "Comma-separated languages to include (default: auto-detect subfolders)")
+	beep         = flag.String("beep", "", "Optional path to beep.wav (default: <in>/beep.wav). If missing, beepSound is omitted.")
+	resampler    = flag.String("resampler", "sinc", "Resampling algorithm to use when converting to 8kHz: sinc|linear")
+	hashManifest = flag.String("hash-manifest", "", "Optional path to write a JSON manifest of {lang, digit, sha256, samples} for the generated assets")
 )
 
-type wavInfo struct {
-	SampleRate    int
-	NumChannels   int
-	BitsPerSample int
-	AudioFormat   uint16 // 1=PCM
-	Data          []byte // raw bytes as in file (interleaved)
-}
-
 // hard-coded header for RIFF/WAVE mono 8kHz 8-bit PCM, with sizes set to 0.
 // This mirrors the original project approach.
 var targetWaveHeader = []byte{
@@ -73,6 +66,15 @@ var targetWaveHeader = []byte{
 type langData struct {
 	Lang   string
 	Digits [10][]byte
+	Hashes [10][32]byte // SHA-256 of each digit's Digits[d], for digitSoundHashes
+}
+
+// manifestEntry is one row of the -hash-manifest JSON output.
+type manifestEntry struct {
+	Lang    string `json:"lang"`
+	Digit   int    `json:"digit"`
+	SHA256  string `json:"sha256"`
+	Samples int    `json:"samples"`
 }
 
 func main() {
@@ -106,19 +108,30 @@ func main() {
 	sort.Strings(langsList)
 
 	var all []langData
+	var manifest []manifestEntry
 
 	for _, lang := range langsList {
 		fmt.Printf(">> Processing lang %q\n", lang)
 		var ld langData
 		ld.Lang = lang
 		for d := 0; d < 10; d++ {
-			path := filepath.Join(*inDir, lang, fmt.Sprintf("%d.wav", d))
+			path, err := findDigitFile(filepath.Join(*inDir, lang), d)
+			if err != nil {
+				fatalf("lang=%s digit=%d: %v", lang, d, err)
+			}
 			u8, err := loadAsMonoU8(path)
 			if err != nil {
 				fatalf("lang=%s digit=%d: %v", lang, d, err)
 			}
 			ld.Digits[d] = u8
-			fmt.Printf("   - %d.wav -> %d samples (8kHz u8)\n", d, len(u8))
+			ld.Hashes[d] = sha256.Sum256(u8)
+			fmt.Printf("   - %s -> %d samples (8kHz u8)\n", filepath.Base(path), len(u8))
+			manifest = append(manifest, manifestEntry{
+				Lang:    lang,
+				Digit:   d,
+				SHA256:  fmt.Sprintf("%x", ld.Hashes[d]),
+				Samples: len(u8),
+			})
 		}
 		all = append(all, ld)
 	}
@@ -138,6 +151,13 @@ func main() {
 		fmt.Printf(">> Beep: %s not found; skipping beepSound generation\n", beepPath)
 	}
 
+	if *hashManifest != "" {
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		check(err)
+		check(os.WriteFile(*hashManifest, data, 0o644))
+		fmt.Printf("OK. Wrote %s (%d entries)\n", *hashManifest, len(manifest))
+	}
+
 	src := generateSource(*pkgName, all, targetWaveHeader, beepBytes)
 	formatted, err := format.Source([]byte(src))
 	if err != nil {
@@ -149,224 +169,70 @@ func main() {
 	fmt.Printf("OK. Wrote %s (%d bytes)\n", *outFile, len(formatted))
 }
 
+// supportedDigitExts lists, in probing order, the source file extensions
+// accepted for a digit slot.
+var supportedDigitExts = audio.SupportedExts
+
 func hasAllDigitFiles(dir string) bool {
 	for d := 0; d < 10; d++ {
-		_, err := os.Stat(filepath.Join(dir, fmt.Sprintf("%d.wav", d)))
-		if err != nil {
+		if _, err := findDigitFile(dir, d); err != nil {
 			return false
 		}
 	}
 	return true
 }
 
-func loadAsMonoU8(path string) ([]byte, error) {
-	w, err := readWAV(path)
-	if err != nil {
-		return nil, fmt.Errorf("read wav: %w", err)
-	}
-	// decode to float mono
-	fmono, sr, err := toFloatMono(w)
-	if err != nil {
-		return nil, fmt.Errorf("to float mono: %w", err)
+// findDigitFile looks for a digit's source file in dir, trying each
+// supported extension in turn (see supportedDigitExts).
+func findDigitFile(dir string, d int) (string, error) {
+	for _, ext := range supportedDigitExts {
+		path := filepath.Join(dir, fmt.Sprintf("%d%s", d, ext))
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
 	}
-	// resample to 8kHz if needed
-	if sr != targetSR {
-		fmono = resampleLinear(fmono, sr, targetSR)
-		sr = targetSR
+	return "", fmt.Errorf("no %d.{%s} found in %s", d, strings.Join(trimDots(supportedDigitExts), ","), dir)
+}
+
+func trimDots(exts []string) []string {
+	out := make([]string, len(exts))
+	for i, e := range exts {
+		out[i] = strings.TrimPrefix(e, ".")
 	}
-	// convert to unsigned 8-bit PCM
-	u8 := floatToU8(fmono)
-	return u8, nil
+	return out
 }
 
-func readWAV(path string) (*wavInfo, error) {
+// loadAsMonoU8 decodes path (dispatching on its extension) and runs it
+// through the shared normalize pipeline: mono mixdown, resample to 8kHz,
+// quantize to unsigned 8-bit PCM.
+func loadAsMonoU8(path string) ([]byte, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	// Read RIFF header
-	var hdr [12]byte
-	if _, err := io.ReadFull(f, hdr[:]); err != nil {
-		return nil, fmt.Errorf("read header: %w", err)
-	}
-	if string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
-		return nil, fmt.Errorf("not a RIFF/WAVE file")
-	}
-
-	var (
-		afmt          uint16
-		channels      uint16
-		sampleRate    uint32
-		bitsPerSample uint16
-		dataChunk     []byte
-	)
-
-	// iterate chunks
-	for {
-		var chdr [8]byte
-		if _, err := io.ReadFull(f, chdr[:]); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, fmt.Errorf("read chunk header: %w", err)
-		}
-		id := string(chdr[0:4])
-		size := binary.LittleEndian.Uint32(chdr[4:8])
-
-		switch id {
-		case "fmt ":
-			buf := make([]byte, size)
-			if _, err := io.ReadFull(f, buf); err != nil {
-				return nil, fmt.Errorf("read fmt: %w", err)
-			}
-			if size < 16 {
-				return nil, fmt.Errorf("fmt chunk too small")
-			}
-			afmt = binary.LittleEndian.Uint16(buf[0:2])
-			channels = binary.LittleEndian.Uint16(buf[2:4])
-			sampleRate = binary.LittleEndian.Uint32(buf[4:8])
-			// skip byteRate[8:12], blockAlign[12:14]
-			bitsPerSample = binary.LittleEndian.Uint16(buf[14:16])
-			// if extra fmt bytes, ignore
-		case "data":
-			dataChunk = make([]byte, size)
-			if _, err := io.ReadFull(f, dataChunk); err != nil {
-				return nil, fmt.Errorf("read data: %w", err)
-			}
-		default:
-			// skip unknown chunk
-			if _, err := f.Seek(int64(size), io.SeekCurrent); err != nil {
-				return nil, fmt.Errorf("seek: %w", err)
-			}
-		}
-		// stop if both parsed
-		if afmt != 0 && dataChunk != nil && sampleRate != 0 && bitsPerSample != 0 && channels != 0 {
-			// continue anyway, in case more chunks follow; data already read
-			// but we can break to be safe
-			// break
-		}
-		// WAV chunks are even-sized; if odd, skip pad byte
-		if size%2 == 1 {
-			if _, err := f.Seek(1, io.SeekCurrent); err != nil {
-				return nil, fmt.Errorf("pad seek: %w", err)
-			}
-		}
-		if dataChunk != nil && afmt != 0 && sampleRate != 0 {
-			break
-		}
-	}
-
-	if dataChunk == nil {
-		return nil, fmt.Errorf("no data chunk")
-	}
-	if afmt != 1 { // PCM
-		return nil, fmt.Errorf("unsupported audio format (want PCM=1), got %d", afmt)
-	}
-	if bitsPerSample != 8 && bitsPerSample != 16 {
-		return nil, fmt.Errorf("unsupported bits per sample: %d (only 8 or 16)", bitsPerSample)
-	}
-	return &wavInfo{
-		SampleRate:    int(sampleRate),
-		NumChannels:   int(channels),
-		BitsPerSample: int(bitsPerSample),
-		AudioFormat:   afmt,
-		Data:          dataChunk,
-	}, nil
-}
-
-func toFloatMono(w *wavInfo) ([]float64, int, error) {
-	// decode to float [-1,1], mixdown to mono if needed
-	switch w.BitsPerSample {
-	case 8:
-		// unsigned 8-bit
-		if w.NumChannels == 1 {
-			n := len(w.Data)
-			out := make([]float64, n)
-			for i := 0; i < n; i++ {
-				out[i] = (float64(uint8(w.Data[i]))/255.0)*2 - 1 // 0..255 -> -1..+1
-			}
-			return out, w.SampleRate, nil
-		}
-		// stereo: average
-		if w.NumChannels == 2 {
-			n := len(w.Data) / 2
-			out := make([]float64, n)
-			for i := 0; i < n; i++ {
-				l := (float64(uint8(w.Data[2*i+0]))/255.0)*2 - 1
-				r := (float64(uint8(w.Data[2*i+1]))/255.0)*2 - 1
-				out[i] = 0.5 * (l + r)
-			}
-			return out, w.SampleRate, nil
-		}
-		return nil, 0, fmt.Errorf("unsupported channels=%d for 8-bit", w.NumChannels)
-
-	case 16:
-		// signed int16 little endian
-		frameBytes := 2 * w.NumChannels
-		if len(w.Data)%frameBytes != 0 {
-			return nil, 0, fmt.Errorf("corrupt data length vs channels")
-		}
-		nFrames := len(w.Data) / frameBytes
-		out := make([]float64, nFrames)
-		if w.NumChannels == 1 {
-			for i := 0; i < nFrames; i++ {
-				v := int16(binary.LittleEndian.Uint16(w.Data[2*i : 2*i+2]))
-				out[i] = float64(v) / 32768.0
-			}
-			return out, w.SampleRate, nil
-		}
-		if w.NumChannels == 2 {
-			for i := 0; i < nFrames; i++ {
-				l := int16(binary.LittleEndian.Uint16(w.Data[4*i : 4*i+2]))
-				r := int16(binary.LittleEndian.Uint16(w.Data[4*i+2 : 4*i+4]))
-				out[i] = 0.5 * (float64(l)/32768.0 + float64(r)/32768.0)
-			}
-			return out, w.SampleRate, nil
-		}
-		return nil, 0, fmt.Errorf("unsupported channels=%d for 16-bit", w.NumChannels)
-	}
-	return nil, 0, fmt.Errorf("unhandled bitsPerSample=%d", w.BitsPerSample)
-}
-
-func resampleLinear(x []float64, srFrom, srTo int) []float64 {
-	if srFrom == srTo || len(x) == 0 {
-		return append([]float64(nil), x...)
-	}
-	duration := float64(len(x)) / float64(srFrom)
-	nTo := int(math.Round(duration * float64(srTo)))
-	if nTo <= 0 {
-		return []float64{}
+	fmono, sr, err := audio.DecodeExt(strings.ToLower(filepath.Ext(path)), f)
+	if err != nil {
+		return nil, fmt.Errorf("decode audio: %w", err)
 	}
-	out := make([]float64, nTo)
-	ratio := float64(srFrom) / float64(srTo)
-	for i := 0; i < nTo; i++ {
-		// position in source
-		pos := float64(i) * ratio
-		idx := int(math.Floor(pos))
-		frac := pos - float64(idx)
-		if idx >= len(x)-1 {
-			out[i] = x[len(x)-1]
-			continue
-		}
-		out[i] = x[idx]*(1-frac) + x[idx+1]*frac
+	if sr != audio.TargetSampleRate {
+		fmono = resample(fmono, sr, audio.TargetSampleRate)
 	}
-	return out
+	return audio.FloatToU8(fmono), nil
 }
 
-func floatToU8(x []float64) []byte {
-	out := make([]byte, len(x))
-	for i, v := range x {
-		if v > 1 {
-			v = 1
-		} else if v < -1 {
-			v = -1
-		}
-		u := uint8(math.Round((v + 1) * 0.5 * 255.0)) // -1..1 -> 0..255
-		out[i] = u
+// resample dispatches to the algorithm selected by -resampler.
+func resample(x []float64, srFrom, srTo int) []float64 {
+	switch *resampler {
+	case "linear":
+		return audio.ResampleLinear(x, srFrom, srTo)
+	case "sinc", "":
+		return audio.ResampleSinc(x, srFrom, srTo)
+	default:
+		fatalf("unknown -resampler %q (want sinc|linear)", *resampler)
+		return nil
 	}
-	return out
 }
 
 func generateSource(pkg string, all []langData, waveHeader []byte, beep []byte) string {
@@ -386,17 +252,50 @@ func generateSource(pkg string, all []langData, waveHeader []byte, beep []byte)
 		}
 		fmt.Fprintf(&b, "\t},\n")
 	}
-	fmt.Fprintf(&b, "}\n")
+	fmt.Fprintf(&b, "}\n\n")
+
+	// digitSoundHashes: SHA-256 of each digitSounds entry, for VerifyAssets.
+	// Assigned via init(), not a var decl, since verify.go already declares
+	// digitSoundHashes at package scope; a second var decl here would be a
+	// redeclaration error.
+	fmt.Fprintf(&b, "// digitSoundHashes holds the SHA-256 of each digit's raw 8 kHz unsigned\n")
+	fmt.Fprintf(&b, "// 8-bit PCM bytes above, checked by VerifyAssets.\n")
+	fmt.Fprintf(&b, "func init() {\n\tdigitSoundHashes = map[string][10][32]byte{\n")
+	for _, ld := range all {
+		fmt.Fprintf(&b, "\t\t%q: {\n", ld.Lang)
+		for d := 0; d < 10; d++ {
+			fmt.Fprintf(&b, "\t\t\t%d: %s, // %d\n", d, formatHashLiteral(ld.Hashes[d]), d)
+		}
+		fmt.Fprintf(&b, "\t\t},\n")
+	}
+	fmt.Fprintf(&b, "\t}\n}\n\n")
 
-	// beepSound (optional)
+	// beepSound and beepSoundHash (optional). Assigned via init(), not a
+	// var decl, so they layer on top of the package's own declarations
+	// instead of redeclaring them; SetBeep can still override both at
+	// runtime (and clears beepSoundHash when it does).
 	if len(beep) > 0 {
-		fmt.Fprintf(&b, "// beepSound contains raw 8 kHz unsigned 8-bit PCM (no WAV header), derived from beep.wav.\n")
-		fmt.Fprintf(&b, "var beepSound = []byte{\n%s}\n", formatBytes(beep, 12))
+		fmt.Fprintf(&b, "func init() {\n\tbeepSound = []byte{ // from beep.wav, 8 kHz unsigned 8-bit PCM, no WAV header\n%s\t}\n\tbeepSoundHash = %s\n}\n",
+			indent(formatBytes(beep, 12), 1), formatHashLiteral(sha256.Sum256(beep)))
 	}
 
 	return b.String()
 }
 
+// formatHashLiteral renders h as a Go [32]byte array literal.
+func formatHashLiteral(h [32]byte) string {
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, v := range h {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "0x%02x", v)
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
 func formatBytes(buf []byte, perLine int) string {
 	var b strings.Builder
 	for i, v := range buf {
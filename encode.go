@@ -0,0 +1,109 @@
+package captcha
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// AudioEncoder writes a concatenated digit+beep PCM stream (mono 8kHz
+// unsigned 8-bit samples, no header) out in some container format.
+type AudioEncoder interface {
+	// WriteAudio encodes pcm and writes it to w.
+	WriteAudio(w io.Writer, pcm []byte) error
+	// ContentType is the MIME type to send alongside WriteAudio's output.
+	ContentType() string
+}
+
+// encoders maps the format names accepted by WriteFormat/ServeAudio to their
+// AudioEncoder.
+var encoders = map[string]AudioEncoder{
+	"wav":  WavEncoder{},
+	"flac": FlacEncoder{},
+	"opus": OpusEncoder{},
+}
+
+var formatMIMEs = map[string]string{
+	"wav":  "audio/wav",
+	"flac": "audio/flac",
+	"opus": "audio/ogg",
+}
+
+// WriteFormat encodes pcm as format ("wav", "flac", or "opus") and writes
+// it to w.
+func WriteFormat(w io.Writer, format string, pcm []byte) error {
+	enc, ok := encoders[format]
+	if !ok {
+		return fmt.Errorf("captcha: unknown audio format %q", format)
+	}
+	return enc.WriteAudio(w, pcm)
+}
+
+// ServeAudio writes pcm to resp in whichever of flac/opus/wav best matches
+// r's Accept header (see negotiateFormat), with a matching Content-Type.
+func ServeAudio(resp http.ResponseWriter, r *http.Request, pcm []byte) error {
+	enc := encoders[negotiateFormat(r.Header.Get("Accept"))]
+	resp.Header().Set("Content-Type", enc.ContentType())
+	return enc.WriteAudio(resp, pcm)
+}
+
+// negotiateFormat picks a format name from an HTTP Accept header, preferring
+// flac (lossless, small) then opus (lossy, smaller) over the wav baseline.
+// It isn't a full RFC 7231 Accept parser (no q-value weighting); it just
+// checks whether either MIME type appears in the header.
+func negotiateFormat(accept string) string {
+	for _, want := range []string{"flac", "opus"} {
+		if strings.Contains(accept, formatMIMEs[want]) {
+			return want
+		}
+	}
+	return "wav"
+}
+
+// PCM concatenates lang's digit sounds (separated by digitGap) followed by
+// beepSound, producing the mono 8kHz unsigned 8-bit PCM stream that
+// WriteFormat/ServeAudio encode.
+func PCM(lang string, digits []int) ([]byte, error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	voice, ok := digitSounds[lang]
+	if !ok {
+		return nil, fmt.Errorf("captcha: unknown language %q", lang)
+	}
+
+	var out []byte
+	for i, d := range digits {
+		if d < 0 || d > 9 {
+			return nil, fmt.Errorf("captcha: digit %d out of range 0-9", d)
+		}
+		if i > 0 {
+			out = append(out, digitGap...)
+		}
+		out = append(out, voice[d]...)
+	}
+	out = append(out, beepSound...)
+	return out, nil
+}
+
+// WavEncoder wraps pcm in the mono 8kHz 8-bit PCM RIFF header generated
+// into sounds.go as waveHeader, patching in the real RIFF and data chunk
+// sizes. This is the format the package has always served.
+type WavEncoder struct{}
+
+func (WavEncoder) ContentType() string { return formatMIMEs["wav"] }
+
+func (WavEncoder) WriteAudio(w io.Writer, pcm []byte) error {
+	if len(waveHeader) != 44 {
+		return fmt.Errorf("captcha: waveHeader is %d bytes, want 44", len(waveHeader))
+	}
+	hdr := append([]byte(nil), waveHeader...)
+	binary.LittleEndian.PutUint32(hdr[4:8], uint32(36+len(pcm)))  // RIFF chunk size
+	binary.LittleEndian.PutUint32(hdr[40:44], uint32(len(pcm))) // data chunk size
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	_, err := w.Write(pcm)
+	return err
+}
@@ -0,0 +1,219 @@
+//go:build opus
+
+// This file requires the opus build tag: github.com/hraban/opus is a cgo
+// binding to libopus/libopusfile, so it's excluded from the default pure-Go
+// build. See encode_opus_stub.go for the !opus fallback.
+
+package captcha
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/hraban/opus"
+	"github.com/xv13r/captcha/internal/audio"
+)
+
+const (
+	// opusEncodeSR is the rate the 8kHz captcha audio is upsampled to
+	// before Opus encoding; Opus itself always operates internally at
+	// 48kHz, but 16kHz is what we feed the encoder (narrowband input).
+	opusEncodeSR     = 16000
+	opusFrameMillis  = 20
+	opusFrameSamples = opusEncodeSR * opusFrameMillis / 1000
+	opusBitrate      = 16000 // ~16kbps VBR narrowband
+
+	// opusGranuleRate is the fixed rate Ogg/Opus granule positions are
+	// always expressed in (RFC 7845 §4), independent of opusEncodeSR.
+	opusGranuleRate = 48000
+	// opusGranuleFrameSamples is how much each opusFrameMillis packet
+	// advances the granule position, in opusGranuleRate units.
+	opusGranuleFrameSamples = opusGranuleRate * opusFrameMillis / 1000
+
+	// opusSerial is a fixed Ogg stream serial number. Each captcha's audio
+	// is served as its own standalone file, never multiplexed with other
+	// streams, so a fixed value is fine.
+	opusSerial = 0x4341504b // "CAPK"
+
+	// opusPreSkipSamples is the OpusHead pre-skip, in opusGranuleRate
+	// (48kHz) units. github.com/hraban/opus's Encoder exposes no lookahead
+	// getter (only SampleRate/Bitrate/Complexity/MaxBandwidth/InBandFEC/
+	// PacketLossPerc/DTX), so this can't be queried at runtime; 5ms is
+	// Opus's documented minimum total algorithmic delay for a SILK-only
+	// encode (our config: AppVoIP + Narrowband bandwidth selects SILK-only),
+	// which is close enough to not clip the start of the audio.
+	opusPreSkipSamples = 5 * opusGranuleRate / 1000
+)
+
+// OpusEncoder upsamples the captcha's 8kHz PCM to 16kHz and encodes it as
+// Ogg/Opus VBR narrowband audio (~16kbps), wrapped in a standard Ogg
+// container: OpusHead + OpusTags + audio pages, per RFC 7845.
+type OpusEncoder struct{}
+
+func (OpusEncoder) ContentType() string { return formatMIMEs["opus"] }
+
+func (OpusEncoder) WriteAudio(w io.Writer, pcm []byte) error {
+	enc, err := opus.NewEncoder(opusEncodeSR, 1, opus.AppVoIP)
+	if err != nil {
+		return fmt.Errorf("captcha: new opus encoder: %w", err)
+	}
+	if err := enc.SetBitrate(opusBitrate); err != nil {
+		return fmt.Errorf("captcha: set opus bitrate: %w", err)
+	}
+	if err := enc.SetMaxBandwidth(opus.Narrowband); err != nil {
+		return fmt.Errorf("captcha: set opus bandwidth: %w", err)
+	}
+
+	pcm16 := floatToInt16(audio.ResampleSinc(u8ToFloatMono(pcm), audio.TargetSampleRate, opusEncodeSR))
+
+	var packets [][]byte
+	buf := make([]byte, 4000)
+	for i := 0; i < len(pcm16); i += opusFrameSamples {
+		frame := pcm16[i:minInt(i+opusFrameSamples, len(pcm16))]
+		if len(frame) < opusFrameSamples {
+			padded := make([]int16, opusFrameSamples)
+			copy(padded, frame)
+			frame = padded
+		}
+		n, err := enc.Encode(frame, buf)
+		if err != nil {
+			return fmt.Errorf("captcha: opus encode: %w", err)
+		}
+		packets = append(packets, append([]byte(nil), buf[:n]...))
+	}
+
+	pages := &oggPageWriter{serial: opusSerial}
+	var out bytes.Buffer
+	out.Write(pages.page([][]byte{opusHeadPacket(uint16(opusPreSkipSamples))}, true, false))
+	out.Write(pages.page([][]byte{opusTagsPacket()}, false, false))
+	for i, packet := range packets {
+		pages.granule += int64(opusGranuleFrameSamples)
+		out.Write(pages.page([][]byte{packet}, false, i == len(packets)-1))
+	}
+
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+func opusHeadPacket(preSkip uint16) []byte {
+	var b bytes.Buffer
+	b.WriteString("OpusHead")
+	b.WriteByte(1) // version
+	b.WriteByte(1) // channel count
+	binary.Write(&b, binary.LittleEndian, preSkip)              // pre-skip, in 48kHz samples (see opusPreSkipSamples)
+	binary.Write(&b, binary.LittleEndian, uint32(opusEncodeSR)) // input sample rate (informational)
+	binary.Write(&b, binary.LittleEndian, int16(0))             // output gain
+	b.WriteByte(0)                                              // channel mapping family 0 (mono/stereo, no table)
+	return b.Bytes()
+}
+
+func opusTagsPacket() []byte {
+	var b bytes.Buffer
+	b.WriteString("OpusTags")
+	const vendor = "captcha"
+	binary.Write(&b, binary.LittleEndian, uint32(len(vendor)))
+	b.WriteString(vendor)
+	binary.Write(&b, binary.LittleEndian, uint32(0)) // no user comments
+	return b.Bytes()
+}
+
+func u8ToFloatMono(pcm []byte) []float64 {
+	out := make([]float64, len(pcm))
+	for i, v := range pcm {
+		out[i] = (float64(v)/255.0)*2 - 1
+	}
+	return out
+}
+
+func floatToInt16(x []float64) []int16 {
+	out := make([]int16, len(x))
+	for i, v := range x {
+		if v > 1 {
+			v = 1
+		} else if v < -1 {
+			v = -1
+		}
+		out[i] = int16(v * 32767)
+	}
+	return out
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// oggPageWriter builds successive Ogg pages for a single logical stream.
+type oggPageWriter struct {
+	serial  uint32
+	seq     uint32
+	granule int64
+}
+
+// page packs packets (lacing them into the page's segment table) into one
+// Ogg page, setting the BOS/EOS flags as requested and computing the page
+// checksum.
+func (pw *oggPageWriter) page(packets [][]byte, bos, eos bool) []byte {
+	var segTable, data []byte
+	for _, p := range packets {
+		segTable = append(segTable, oggLacingValues(len(p))...)
+		data = append(data, p...)
+	}
+
+	var hdr bytes.Buffer
+	hdr.WriteString("OggS")
+	hdr.WriteByte(0) // stream structure version
+	var flags byte
+	if bos {
+		flags |= 0x02
+	}
+	if eos {
+		flags |= 0x04
+	}
+	hdr.WriteByte(flags)
+	binary.Write(&hdr, binary.LittleEndian, pw.granule)
+	binary.Write(&hdr, binary.LittleEndian, pw.serial)
+	binary.Write(&hdr, binary.LittleEndian, pw.seq)
+	binary.Write(&hdr, binary.LittleEndian, uint32(0)) // checksum placeholder
+	hdr.WriteByte(byte(len(segTable)))
+	hdr.Write(segTable)
+
+	page := append(hdr.Bytes(), data...)
+	binary.LittleEndian.PutUint32(page[22:26], oggCRC32(page))
+	pw.seq++
+	return page
+}
+
+// oggLacingValues splits a packet's length into Ogg's lacing-value form:
+// as many 255s as needed, followed by the remainder (0 if the length is an
+// exact multiple of 255).
+func oggLacingValues(n int) []byte {
+	var out []byte
+	for n >= 255 {
+		out = append(out, 255)
+		n -= 255
+	}
+	return append(out, byte(n))
+}
+
+// oggCRC32 is Ogg's page checksum: polynomial 0x04c11db7, MSB-first, no
+// reflection, zero initial value (distinct from the reflected CRC-32 used
+// by zip/gzip).
+func oggCRC32(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
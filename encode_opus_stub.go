@@ -0,0 +1,20 @@
+//go:build !opus
+
+package captcha
+
+import (
+	"fmt"
+	"io"
+)
+
+// OpusEncoder is the fallback used when this binary isn't built with the
+// opus tag: github.com/hraban/opus is a cgo binding to libopus/libopusfile,
+// so real Ogg/Opus encoding (encode_opus.go) is excluded from the default
+// pure-Go build. Rebuild with -tags opus for real output.
+type OpusEncoder struct{}
+
+func (OpusEncoder) ContentType() string { return formatMIMEs["opus"] }
+
+func (OpusEncoder) WriteAudio(w io.Writer, pcm []byte) error {
+	return fmt.Errorf("captcha: opus support not built into this binary (rebuild with -tags opus)")
+}
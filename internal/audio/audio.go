@@ -0,0 +1,386 @@
+// Package audio is the shared decode/normalize pipeline for captcha voice
+// assets: decode a source file (WAV, FLAC, or Ogg/Opus), mix it down to
+// mono, resample to the 8kHz captcha target rate, and quantize to unsigned
+// 8-bit PCM. cmd/generate uses it to build sounds.go at build time; the
+// captcha package itself uses it for the runtime RegisterLanguage /
+// RegisterDigit API.
+//
+// Ogg/Opus decoding (DecodeOggOpus, opus.go/opus_stub.go) needs the opus
+// build tag, since github.com/hraban/opus is a cgo binding to
+// libopus/libopusfile; WAV and FLAC decoding here are pure Go.
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/mewkiz/flac"
+)
+
+// Target audio format for captcha: mono 8kHz unsigned 8-bit PCM (no header).
+const (
+	TargetSampleRate = 8000
+	TargetBits       = 8
+	TargetChannels   = 1
+)
+
+// SupportedExts lists, in probing order, the source file extensions this
+// package can decode.
+var SupportedExts = []string{".wav", ".flac", ".ogg", ".opus"}
+
+// WAVInfo holds the parsed fields of a RIFF/WAVE file needed to decode it.
+type WAVInfo struct {
+	SampleRate    int
+	NumChannels   int
+	BitsPerSample int
+	AudioFormat   uint16 // 1=PCM
+	Data          []byte // raw bytes as in file (interleaved)
+}
+
+// DecodeExt decodes r as the format named by ext (one of SupportedExts, with
+// or without the leading dot) to float64 mono in [-1, 1], returning its
+// native sample rate.
+func DecodeExt(ext string, r io.Reader) ([]float64, int, error) {
+	switch ext {
+	case ".wav", "wav":
+		w, err := DecodeWAV(r)
+		if err != nil {
+			return nil, 0, fmt.Errorf("read wav: %w", err)
+		}
+		return ToFloatMono(w)
+	case ".flac", "flac":
+		return DecodeFLAC(r)
+	case ".ogg", "ogg", ".opus", "opus":
+		return DecodeOggOpus(r)
+	default:
+		return nil, 0, fmt.Errorf("unsupported audio extension %q", ext)
+	}
+}
+
+// DecodeAuto sniffs r's container from its leading magic bytes and decodes
+// it to float64 mono in [-1, 1], returning its native sample rate. Use this
+// when no filename extension is available, e.g. RegisterLanguage.
+func DecodeAuto(r io.Reader) ([]float64, int, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read magic: %w", err)
+	}
+	switch {
+	case bytes.Equal(magic, []byte("RIFF")):
+		return DecodeExt(".wav", br)
+	case bytes.Equal(magic, []byte("fLaC")):
+		return DecodeExt(".flac", br)
+	case bytes.Equal(magic, []byte("OggS")):
+		return DecodeExt(".ogg", br)
+	default:
+		return nil, 0, fmt.Errorf("unrecognized audio format (magic %q)", magic)
+	}
+}
+
+// ToU8 runs the full normalize pipeline on already-decoded float64 mono
+// samples: resample to TargetSampleRate (if needed) and quantize to
+// unsigned 8-bit PCM.
+func ToU8(fmono []float64, sr int) []byte {
+	if sr != TargetSampleRate {
+		fmono = ResampleSinc(fmono, sr, TargetSampleRate)
+	}
+	return FloatToU8(fmono)
+}
+
+// DecodeWAV reads a RIFF/WAVE stream and returns its format fields and raw
+// (still interleaved, still fixed/float-native) sample data.
+func DecodeWAV(r io.Reader) (*WAVInfo, error) {
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("not a RIFF/WAVE file")
+	}
+
+	var (
+		afmt          uint16
+		channels      uint16
+		sampleRate    uint32
+		bitsPerSample uint16
+		dataChunk     []byte
+	)
+
+	for {
+		var chdr [8]byte
+		if _, err := io.ReadFull(r, chdr[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("read chunk header: %w", err)
+		}
+		id := string(chdr[0:4])
+		size := binary.LittleEndian.Uint32(chdr[4:8])
+
+		switch id {
+		case "fmt ":
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, fmt.Errorf("read fmt: %w", err)
+			}
+			if size < 16 {
+				return nil, fmt.Errorf("fmt chunk too small")
+			}
+			afmt = binary.LittleEndian.Uint16(buf[0:2])
+			channels = binary.LittleEndian.Uint16(buf[2:4])
+			sampleRate = binary.LittleEndian.Uint32(buf[4:8])
+			bitsPerSample = binary.LittleEndian.Uint16(buf[14:16])
+		case "data":
+			dataChunk = make([]byte, size)
+			if _, err := io.ReadFull(r, dataChunk); err != nil {
+				return nil, fmt.Errorf("read data: %w", err)
+			}
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
+				return nil, fmt.Errorf("skip chunk: %w", err)
+			}
+		}
+		if size%2 == 1 {
+			if _, err := io.CopyN(io.Discard, r, 1); err != nil {
+				return nil, fmt.Errorf("pad skip: %w", err)
+			}
+		}
+		if dataChunk != nil && afmt != 0 && sampleRate != 0 {
+			break
+		}
+	}
+
+	if dataChunk == nil {
+		return nil, fmt.Errorf("no data chunk")
+	}
+	if afmt != 1 { // PCM
+		return nil, fmt.Errorf("unsupported audio format (want PCM=1), got %d", afmt)
+	}
+	if bitsPerSample != 8 && bitsPerSample != 16 {
+		return nil, fmt.Errorf("unsupported bits per sample: %d (only 8 or 16)", bitsPerSample)
+	}
+	return &WAVInfo{
+		SampleRate:    int(sampleRate),
+		NumChannels:   int(channels),
+		BitsPerSample: int(bitsPerSample),
+		AudioFormat:   afmt,
+		Data:          dataChunk,
+	}, nil
+}
+
+// ToFloatMono decodes w to float64 mono in [-1, 1], mixing stereo down by
+// averaging channels.
+func ToFloatMono(w *WAVInfo) ([]float64, int, error) {
+	switch w.BitsPerSample {
+	case 8:
+		if w.NumChannels == 1 {
+			n := len(w.Data)
+			out := make([]float64, n)
+			for i := 0; i < n; i++ {
+				out[i] = (float64(uint8(w.Data[i]))/255.0)*2 - 1 // 0..255 -> -1..+1
+			}
+			return out, w.SampleRate, nil
+		}
+		if w.NumChannels == 2 {
+			n := len(w.Data) / 2
+			out := make([]float64, n)
+			for i := 0; i < n; i++ {
+				l := (float64(uint8(w.Data[2*i+0]))/255.0)*2 - 1
+				r := (float64(uint8(w.Data[2*i+1]))/255.0)*2 - 1
+				out[i] = 0.5 * (l + r)
+			}
+			return out, w.SampleRate, nil
+		}
+		return nil, 0, fmt.Errorf("unsupported channels=%d for 8-bit", w.NumChannels)
+
+	case 16:
+		frameBytes := 2 * w.NumChannels
+		if len(w.Data)%frameBytes != 0 {
+			return nil, 0, fmt.Errorf("corrupt data length vs channels")
+		}
+		nFrames := len(w.Data) / frameBytes
+		out := make([]float64, nFrames)
+		if w.NumChannels == 1 {
+			for i := 0; i < nFrames; i++ {
+				v := int16(binary.LittleEndian.Uint16(w.Data[2*i : 2*i+2]))
+				out[i] = float64(v) / 32768.0
+			}
+			return out, w.SampleRate, nil
+		}
+		if w.NumChannels == 2 {
+			for i := 0; i < nFrames; i++ {
+				l := int16(binary.LittleEndian.Uint16(w.Data[4*i : 4*i+2]))
+				r := int16(binary.LittleEndian.Uint16(w.Data[4*i+2 : 4*i+4]))
+				out[i] = 0.5 * (float64(l)/32768.0 + float64(r)/32768.0)
+			}
+			return out, w.SampleRate, nil
+		}
+		return nil, 0, fmt.Errorf("unsupported channels=%d for 16-bit", w.NumChannels)
+	}
+	return nil, 0, fmt.Errorf("unhandled bitsPerSample=%d", w.BitsPerSample)
+}
+
+// DecodeFLAC decodes a FLAC stream to float64 mono in [-1, 1], mixing down
+// multi-channel streams the same way ToFloatMono does for WAV.
+func DecodeFLAC(r io.Reader) ([]float64, int, error) {
+	stream, err := flac.Parse(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("parse flac: %w", err)
+	}
+	defer stream.Close()
+
+	sr := int(stream.Info.SampleRate)
+	channels := int(stream.Info.NChannels)
+	maxVal := float64(int64(1) << (stream.Info.BitsPerSample - 1))
+
+	var mono []float64
+	for {
+		frame, err := stream.ParseNext()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("parse flac frame: %w", err)
+		}
+		n := len(frame.Subframes[0].Samples)
+		for i := 0; i < n; i++ {
+			var sum float64
+			for c := 0; c < channels; c++ {
+				sum += float64(frame.Subframes[c].Samples[i])
+			}
+			mono = append(mono, (sum/float64(channels))/maxVal)
+		}
+	}
+	return mono, sr, nil
+}
+
+// ResampleLinear converts between sample rates via simple linear
+// interpolation.
+func ResampleLinear(x []float64, srFrom, srTo int) []float64 {
+	if srFrom == srTo || len(x) == 0 {
+		return append([]float64(nil), x...)
+	}
+	duration := float64(len(x)) / float64(srFrom)
+	nTo := int(math.Round(duration * float64(srTo)))
+	if nTo <= 0 {
+		return []float64{}
+	}
+	out := make([]float64, nTo)
+	ratio := float64(srFrom) / float64(srTo)
+	for i := 0; i < nTo; i++ {
+		pos := float64(i) * ratio
+		idx := int(math.Floor(pos))
+		frac := pos - float64(idx)
+		if idx >= len(x)-1 {
+			out[i] = x[len(x)-1]
+			continue
+		}
+		out[i] = x[idx]*(1-frac) + x[idx+1]*frac
+	}
+	return out
+}
+
+// sincTaps is the half-width (in output-rate terms) of the windowed-sinc
+// kernel; the full kernel spans sincTaps*2 input samples around each output
+// position.
+const sincTaps = 32
+
+// sincBeta is the Kaiser window shape parameter, chosen for a good
+// stopband/transition tradeoff at this tap count.
+const sincBeta = 8.6
+
+// ResampleSinc resamples x from srFrom to srTo using a Kaiser-windowed sinc
+// kernel. When downsampling, the kernel cutoff is scaled by srTo/srFrom so
+// it doubles as an anti-alias low-pass filter.
+func ResampleSinc(x []float64, srFrom, srTo int) []float64 {
+	if srFrom == srTo || len(x) == 0 {
+		return append([]float64(nil), x...)
+	}
+	duration := float64(len(x)) / float64(srFrom)
+	nTo := int(math.Round(duration * float64(srTo)))
+	if nTo <= 0 {
+		return []float64{}
+	}
+
+	cutoff := 1.0
+	if srTo < srFrom {
+		cutoff = float64(srTo) / float64(srFrom)
+	}
+	ratio := float64(srFrom) / float64(srTo)
+
+	out := make([]float64, nTo)
+	for i := 0; i < nTo; i++ {
+		pos := float64(i) * ratio
+		center := int(math.Floor(pos))
+		frac := pos - float64(center)
+
+		var sum, norm float64
+		for k := -sincTaps + 1; k <= sincTaps; k++ {
+			srcIdx := center + k
+			if srcIdx < 0 || srcIdx >= len(x) {
+				continue
+			}
+			d := float64(k) - frac
+			w := sincKernel(d, cutoff, sincTaps, sincBeta)
+			sum += w * x[srcIdx]
+			norm += w
+		}
+		if norm != 0 {
+			sum /= norm
+		}
+		out[i] = sum
+	}
+	return out
+}
+
+// sincKernel evaluates a Kaiser-windowed, cutoff-scaled sinc at distance d
+// (in input samples) from the tap center. half is the kernel half-width.
+func sincKernel(d, cutoff float64, half int, beta float64) float64 {
+	if math.Abs(d) >= float64(half) {
+		return 0
+	}
+	var sinc float64
+	x := cutoff * d
+	if math.Abs(x) < 1e-9 {
+		sinc = 1
+	} else {
+		sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+	}
+	r := d / float64(half)
+	w := math.Sqrt(1 - r*r)
+	window := besselI0(beta*w) / besselI0(beta)
+	return cutoff * sinc * window
+}
+
+// besselI0 computes the zeroth-order modified Bessel function of the first
+// kind via its power series; a handful of terms converge to float64
+// precision for the beta values used by the Kaiser window here.
+func besselI0(x float64) float64 {
+	sum := 1.0
+	term := 1.0
+	halfX := x / 2
+	for k := 1; k < 25; k++ {
+		term *= (halfX * halfX) / float64(k*k)
+		sum += term
+	}
+	return sum
+}
+
+// FloatToU8 quantizes float64 samples in [-1, 1] to unsigned 8-bit PCM.
+func FloatToU8(x []float64) []byte {
+	out := make([]byte, len(x))
+	for i, v := range x {
+		if v > 1 {
+			v = 1
+		} else if v < -1 {
+			v = -1
+		}
+		out[i] = uint8(math.Round((v + 1) * 0.5 * 255.0)) // -1..1 -> 0..255
+	}
+	return out
+}
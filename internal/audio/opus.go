@@ -0,0 +1,37 @@
+//go:build opus
+
+package audio
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hraban/opus"
+)
+
+// DecodeOggOpus decodes an Ogg/Opus stream to float64 mono in [-1, 1]. Opus
+// always decodes at 48kHz regardless of the original encoding bandwidth;
+// callers resample to TargetSampleRate like any other source.
+func DecodeOggOpus(r io.Reader) ([]float64, int, error) {
+	const opusSR = 48000
+	stream, err := opus.NewStream(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("open ogg/opus stream: %w", err)
+	}
+
+	var mono []float64
+	buf := make([]int16, 5760) // max Opus frame size at 48kHz (120ms, mono)
+	for {
+		n, err := stream.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("decode opus packet: %w", err)
+		}
+		for i := 0; i < n; i++ {
+			mono = append(mono, float64(buf[i])/32768.0)
+		}
+	}
+	return mono, opusSR, nil
+}
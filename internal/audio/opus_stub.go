@@ -0,0 +1,16 @@
+//go:build !opus
+
+package audio
+
+import (
+	"fmt"
+	"io"
+)
+
+// DecodeOggOpus is the fallback used when this binary isn't built with the
+// opus tag: github.com/hraban/opus is a cgo binding to libopus/libopusfile,
+// so real Ogg/Opus decoding (opus.go) is excluded from the default pure-Go
+// build. Rebuild with -tags opus to decode .ogg/.opus sources.
+func DecodeOggOpus(r io.Reader) ([]float64, int, error) {
+	return nil, 0, fmt.Errorf("audio: Ogg/Opus decoding not built into this binary (rebuild with -tags opus)")
+}
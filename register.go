@@ -0,0 +1,95 @@
+package captcha
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/xv13r/captcha/internal/audio"
+)
+
+// registryMu guards digitSounds against concurrent Register* calls; the
+// generated map itself (sounds.go) is only ever written at init time.
+var registryMu sync.Mutex
+
+// RegisterLanguage decodes digits (one reader per digit 0-9) and installs
+// them as lang's voice pack, overwriting any existing entry for lang. Each
+// reader is run through the same normalize pipeline cmd/generate uses to
+// build sounds.go: mono mixdown, resample to 8kHz, quantize to unsigned
+// 8-bit PCM. The source format (WAV, FLAC, or Ogg/Opus) is detected from
+// each reader's leading bytes, so callers don't need to know it up front.
+//
+// This lets applications ship their own voice packs, or per-tenant custom
+// digits, without regenerating sounds.go and recompiling.
+func RegisterLanguage(lang string, digits [10]io.Reader) error {
+	var u8s [10][]byte
+	for d, r := range digits {
+		if r == nil {
+			return fmt.Errorf("captcha: RegisterLanguage %q: digit %d reader is nil", lang, d)
+		}
+		u8, err := decodeDigit(r)
+		if err != nil {
+			return fmt.Errorf("captcha: RegisterLanguage %q: digit %d: %w", lang, d, err)
+		}
+		u8s[d] = u8
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if digitSounds == nil {
+		digitSounds = make(map[string][][]byte)
+	}
+	slot := make([][]byte, 10)
+	copy(slot, u8s[:])
+	digitSounds[lang] = slot
+	// The whole language was just replaced; any baked-in hashes for it no
+	// longer apply, so VerifyAssets shouldn't check against them.
+	delete(digitSoundHashes, lang)
+	return nil
+}
+
+// RegisterDigit decodes r and installs it as digit d of lang, leaving the
+// rest of that language's digits untouched. lang need not already exist;
+// any slots not yet registered are left nil.
+func RegisterDigit(lang string, d int, r io.Reader) error {
+	if d < 0 || d > 9 {
+		return fmt.Errorf("captcha: RegisterDigit %q: digit %d out of range 0-9", lang, d)
+	}
+	u8, err := decodeDigit(r)
+	if err != nil {
+		return fmt.Errorf("captcha: RegisterDigit %q: digit %d: %w", lang, d, err)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if digitSounds == nil {
+		digitSounds = make(map[string][][]byte)
+	}
+	slot, ok := digitSounds[lang]
+	if !ok || len(slot) != 10 {
+		slot = make([][]byte, 10)
+	}
+	slot[d] = u8
+	digitSounds[lang] = slot
+	// That digit was just replaced; clear its baked-in hash (if any) so
+	// VerifyAssets doesn't flag it as corrupted.
+	if hashes, ok := digitSoundHashes[lang]; ok {
+		hashes[d] = [32]byte{}
+		digitSoundHashes[lang] = hashes
+	}
+	return nil
+}
+
+// decodeDigit runs r through the shared audio pipeline: sniff its container
+// format, decode to mono, resample to 8kHz, and quantize to unsigned 8-bit
+// PCM.
+func decodeDigit(r io.Reader) ([]byte, error) {
+	fmono, sr, err := audio.DecodeAuto(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	if sr != audio.TargetSampleRate {
+		fmono = audio.ResampleSinc(fmono, sr, audio.TargetSampleRate)
+	}
+	return audio.FloatToU8(fmono), nil
+}
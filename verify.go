@@ -0,0 +1,49 @@
+package captcha
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// digitSoundHashes holds the SHA-256 of each digit's raw unsigned 8-bit PCM
+// samples, as emitted into sounds.go by cmd/generate. A zero hash means "no
+// baked-in expectation" (never generated, or overridden at runtime by
+// RegisterLanguage/RegisterDigit) and VerifyAssets skips it.
+var digitSoundHashes = map[string][10][32]byte{}
+
+// beepSoundHash is beepSound's SHA-256 as baked in by cmd/generate. Like
+// digitSoundHashes, a zero value means "no expectation" and is skipped.
+var beepSoundHash [32]byte
+
+// VerifyAssets recomputes SHA-256 over every in-memory digit and beep sound
+// that has a baked-in hash and returns an error describing the first
+// mismatch. Run it at init or in a test to catch in-memory corruption or a
+// truncated/hand-edited sounds.go; it does not flag assets installed at
+// runtime via RegisterLanguage/RegisterDigit/SetBeep, since those have no
+// baked-in hash to check against.
+func VerifyAssets() error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for lang, hashes := range digitSoundHashes {
+		voice, ok := digitSounds[lang]
+		if !ok {
+			continue // lang's assets were removed/replaced at runtime
+		}
+		for d, want := range hashes {
+			if want == ([32]byte{}) {
+				continue
+			}
+			if got := sha256.Sum256(voice[d]); got != want {
+				return fmt.Errorf("captcha: VerifyAssets: lang %q digit %d: sha256 mismatch (asset corrupted or truncated)", lang, d)
+			}
+		}
+	}
+
+	if beepSoundHash != ([32]byte{}) {
+		if got := sha256.Sum256(beepSound); got != beepSoundHash {
+			return fmt.Errorf("captcha: VerifyAssets: beepSound: sha256 mismatch (asset corrupted or truncated)")
+		}
+	}
+	return nil
+}